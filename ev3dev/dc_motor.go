@@ -17,6 +17,12 @@ type DCMotor struct {
 	id int
 
 	err error
+
+	commandsCache    []string
+	stopActionsCache []string
+
+	haveConfig bool
+	config     Config
 }
 
 // Path returns the dc-motor sysfs path.
@@ -57,9 +63,28 @@ func DCMotorFor(port, driver string) (*DCMotor, error) {
 	return &DCMotor{id: id}, err
 }
 
-// Commands returns the available commands for the DCMotor.
+// Commands returns the available commands for the DCMotor. The result is
+// cached until Refresh is called.
 func (m *DCMotor) Commands() ([]string, error) {
-	return stringSliceFrom(attributeOf(m, commands))
+	if m.commandsCache != nil {
+		return append([]string(nil), m.commandsCache...), nil
+	}
+	avail, err := stringSliceFrom(tracedAttributeOf(m, commands))
+	if err != nil {
+		return nil, err
+	}
+	m.commandsCache = avail
+	return append([]string(nil), avail...), nil
+}
+
+// Refresh invalidates the DCMotor's cached enum-valued attribute lists
+// and the last-applied Configure state, forcing the next call to
+// Commands, StopActions or Configure to re-read or re-write from
+// scratch.
+func (m *DCMotor) Refresh() {
+	m.commandsCache = nil
+	m.stopActionsCache = nil
+	m.haveConfig = false
 }
 
 // Command issues a command to the DCMotor.
@@ -83,18 +108,18 @@ func (m *DCMotor) Command(comm string) *DCMotor {
 		m.err = fmt.Errorf("ev3dev: command %q not available for %s (available:%q)", comm, m, avail)
 		return m
 	}
-	m.err = setAttributeOf(m, command, comm)
+	m.err = tracedSetAttributeOf(m, command, comm)
 	return m
 }
 
 // DutyCycle returns the current duty cycle value for the DCMotor.
 func (m *DCMotor) DutyCycle() (int, error) {
-	return intFrom(attributeOf(m, dutyCycle))
+	return intFrom(tracedAttributeOf(m, dutyCycle))
 }
 
 // DutyCycleSetpoint returns the current duty cycle setpoint value for the DCMotor.
 func (m *DCMotor) DutyCycleSetpoint() (int, error) {
-	return intFrom(attributeOf(m, dutyCycleSetpoint))
+	return intFrom(tracedAttributeOf(m, dutyCycleSetpoint))
 }
 
 // SetDutyCycleSetpoint sets the duty cycle setpoint value for the DCMotor
@@ -106,13 +131,13 @@ func (m *DCMotor) SetDutyCycleSetpoint(sp int) *DCMotor {
 		m.err = fmt.Errorf("ev3dev: invalid duty cycle setpoint: %d (valid -100 - 100)", sp)
 		return m
 	}
-	m.err = setAttributeOf(m, dutyCycleSetpoint, fmt.Sprint(sp))
+	m.err = tracedSetAttributeOf(m, dutyCycleSetpoint, fmt.Sprint(sp))
 	return m
 }
 
 // Polarity returns the current polarity of the DCMotor.
 func (m *DCMotor) Polarity() (Polarity, error) {
-	p, err := stringFrom(attributeOf(m, polarity))
+	p, err := stringFrom(tracedAttributeOf(m, polarity))
 	return Polarity(p), err
 }
 
@@ -125,13 +150,13 @@ func (m *DCMotor) SetPolarity(p Polarity) *DCMotor {
 		m.err = fmt.Errorf("ev3dev: invalid polarity: %q (valid \"normal\" or \"inversed\")", p)
 		return m
 	}
-	m.err = setAttributeOf(m, polarity, string(p))
+	m.err = tracedSetAttributeOf(m, polarity, string(p))
 	return m
 }
 
 // RampUpSetpoint returns the current ramp up setpoint value for the DCMotor.
 func (m *DCMotor) RampUpSetpoint() (time.Duration, error) {
-	return durationFrom(attributeOf(m, rampUpSetpoint))
+	return durationFrom(tracedAttributeOf(m, rampUpSetpoint))
 }
 
 // SetRampUpSetpoint sets the ramp up setpoint value for the DCMotor.
@@ -143,13 +168,13 @@ func (m *DCMotor) SetRampUpSetpoint(sp time.Duration) *DCMotor {
 		m.err = fmt.Errorf("ev3dev: invalid ramp up setpoint: %v (must be positive)", sp)
 		return m
 	}
-	m.err = setAttributeOf(m, rampUpSetpoint, fmt.Sprint(int(sp/time.Millisecond)))
+	m.err = tracedSetAttributeOf(m, rampUpSetpoint, fmt.Sprint(int(sp/time.Millisecond)))
 	return m
 }
 
 // RampDownSetpoint returns the current ramp down setpoint value for the DCMotor.
 func (m *DCMotor) RampDownSetpoint() (time.Duration, error) {
-	return durationFrom(attributeOf(m, rampDownSetpoint))
+	return durationFrom(tracedAttributeOf(m, rampDownSetpoint))
 }
 
 // SetRampDownSetpoint sets the ramp down setpoint value for the DCMotor.
@@ -161,7 +186,7 @@ func (m *DCMotor) SetRampDownSetpoint(sp time.Duration) *DCMotor {
 		m.err = fmt.Errorf("ev3dev: invalid ramp down setpoint: %v (must be positive)", sp)
 		return m
 	}
-	m.err = setAttributeOf(m, rampDownSetpoint, fmt.Sprint(int(sp/time.Millisecond)))
+	m.err = tracedSetAttributeOf(m, rampDownSetpoint, fmt.Sprint(int(sp/time.Millisecond)))
 	return m
 }
 
@@ -170,7 +195,7 @@ func (m *DCMotor) State() (MotorState, error) {
 	if m.err != nil {
 		return 0, m.Err()
 	}
-	data, _, err := attributeOf(m, state)
+	data, _, err := tracedAttributeOf(m, state)
 	if err != nil {
 		return 0, err
 	}
@@ -188,7 +213,7 @@ func (m *DCMotor) State() (MotorState, error) {
 // StopAction returns the stop action used when a stop command is issued
 // to the DCMotor.
 func (m *DCMotor) StopAction() (string, error) {
-	return stringFrom(attributeOf(m, stopAction))
+	return stringFrom(tracedAttributeOf(m, stopAction))
 }
 
 // SetStopAction sets the stop action to be used when a stop command is
@@ -213,18 +238,27 @@ func (m *DCMotor) SetStopAction(action string) *DCMotor {
 		m.err = fmt.Errorf("ev3dev: stop action %q not available for %s (available:%q)", action, m, avail)
 		return m
 	}
-	m.err = setAttributeOf(m, stopAction, action)
+	m.err = tracedSetAttributeOf(m, stopAction, action)
 	return m
 }
 
-// StopActions returns the available stop actions for the DCMotor.
+// StopActions returns the available stop actions for the DCMotor. The
+// result is cached until Refresh is called.
 func (m *DCMotor) StopActions() ([]string, error) {
-	return stringSliceFrom(attributeOf(m, stopActions))
+	if m.stopActionsCache != nil {
+		return append([]string(nil), m.stopActionsCache...), nil
+	}
+	avail, err := stringSliceFrom(tracedAttributeOf(m, stopActions))
+	if err != nil {
+		return nil, err
+	}
+	m.stopActionsCache = avail
+	return append([]string(nil), avail...), nil
 }
 
 // TimeSetpoint returns the current time setpoint value for the DCMotor.
 func (m *DCMotor) TimeSetpoint() (time.Duration, error) {
-	return durationFrom(attributeOf(m, timeSetpoint))
+	return durationFrom(tracedAttributeOf(m, timeSetpoint))
 }
 
 // SetTimeSetpoint sets the time setpoint value for the DCMotor.
@@ -232,11 +266,103 @@ func (m *DCMotor) SetTimeSetpoint(sp time.Duration) *DCMotor {
 	if m.err != nil {
 		return m
 	}
-	m.err = setAttributeOf(m, timeSetpoint, fmt.Sprint(int(sp/time.Millisecond)))
+	m.err = tracedSetAttributeOf(m, timeSetpoint, fmt.Sprint(int(sp/time.Millisecond)))
 	return m
 }
 
 // Uevent returns the current uevent state for the DCMotor.
 func (m *DCMotor) Uevent() (map[string]string, error) {
-	return ueventFrom(attributeOf(m, uevent))
+	return ueventFrom(tracedAttributeOf(m, uevent))
+}
+
+// Config holds a set of attribute values to apply to a DCMotor with
+// Configure. A nil field is left unchanged.
+type Config struct {
+	DutyCycleSetpoint *int
+	Polarity          *Polarity
+	RampUpSetpoint    *time.Duration
+	RampDownSetpoint  *time.Duration
+	StopAction        *string
+	TimeSetpoint      *time.Duration
+
+	// Command, if set, is always issued, after every other field in cfg
+	// has been applied, so that setpoints are in place before the motor
+	// starts.
+	Command *string
+}
+
+// Configure applies cfg to the DCMotor, writing only the fields that
+// differ from the DCMotor's last-applied Config, in a fixed order with
+// Command issued last. Use Refresh to discard the cached Config and
+// force every non-nil field in the next Configure call to be written.
+func (m *DCMotor) Configure(cfg Config) *DCMotor {
+	if m.err != nil {
+		return m
+	}
+	prev := m.config
+	have := m.haveConfig
+
+	if cfg.DutyCycleSetpoint != nil && (!have || prev.DutyCycleSetpoint == nil || *prev.DutyCycleSetpoint != *cfg.DutyCycleSetpoint) {
+		if m.SetDutyCycleSetpoint(*cfg.DutyCycleSetpoint); m.err != nil {
+			return m
+		}
+	}
+	if cfg.Polarity != nil && (!have || prev.Polarity == nil || *prev.Polarity != *cfg.Polarity) {
+		if m.SetPolarity(*cfg.Polarity); m.err != nil {
+			return m
+		}
+	}
+	if cfg.RampUpSetpoint != nil && (!have || prev.RampUpSetpoint == nil || *prev.RampUpSetpoint != *cfg.RampUpSetpoint) {
+		if m.SetRampUpSetpoint(*cfg.RampUpSetpoint); m.err != nil {
+			return m
+		}
+	}
+	if cfg.RampDownSetpoint != nil && (!have || prev.RampDownSetpoint == nil || *prev.RampDownSetpoint != *cfg.RampDownSetpoint) {
+		if m.SetRampDownSetpoint(*cfg.RampDownSetpoint); m.err != nil {
+			return m
+		}
+	}
+	if cfg.StopAction != nil && (!have || prev.StopAction == nil || *prev.StopAction != *cfg.StopAction) {
+		if m.SetStopAction(*cfg.StopAction); m.err != nil {
+			return m
+		}
+	}
+	if cfg.TimeSetpoint != nil && (!have || prev.TimeSetpoint == nil || *prev.TimeSetpoint != *cfg.TimeSetpoint) {
+		if m.SetTimeSetpoint(*cfg.TimeSetpoint); m.err != nil {
+			return m
+		}
+	}
+
+	if cfg.DutyCycleSetpoint != nil {
+		v := *cfg.DutyCycleSetpoint
+		m.config.DutyCycleSetpoint = &v
+	}
+	if cfg.Polarity != nil {
+		v := *cfg.Polarity
+		m.config.Polarity = &v
+	}
+	if cfg.RampUpSetpoint != nil {
+		v := *cfg.RampUpSetpoint
+		m.config.RampUpSetpoint = &v
+	}
+	if cfg.RampDownSetpoint != nil {
+		v := *cfg.RampDownSetpoint
+		m.config.RampDownSetpoint = &v
+	}
+	if cfg.StopAction != nil {
+		v := *cfg.StopAction
+		m.config.StopAction = &v
+	}
+	if cfg.TimeSetpoint != nil {
+		v := *cfg.TimeSetpoint
+		m.config.TimeSetpoint = &v
+	}
+	m.haveConfig = true
+
+	if cfg.Command != nil {
+		if m.Command(*cfg.Command); m.err != nil {
+			return m
+		}
+	}
+	return m
 }
@@ -0,0 +1,58 @@
+// Copyright ©2016 The ev3go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ev3dev
+
+import (
+	"testing"
+	"time"
+)
+
+type countingTracer struct {
+	writes map[string]int
+}
+
+func (c *countingTracer) OnRead(dev Device, attr, value string, err error, dur time.Duration) {}
+
+func (c *countingTracer) OnWrite(dev Device, attr, value string, err error, dur time.Duration) {
+	if c.writes == nil {
+		c.writes = make(map[string]int)
+	}
+	c.writes[attr]++
+}
+
+func TestConfigureMergesAcrossCalls(t *testing.T) {
+	rec := &countingTracer{}
+	SetTracer(rec)
+	defer SetTracer(nil)
+
+	m := &DCMotor{id: 0}
+
+	sp := 50
+	m.Configure(Config{DutyCycleSetpoint: &sp})
+	if err := m.Err(); err != nil {
+		t.Fatalf("Configure: %v", err)
+	}
+
+	tm := 200 * time.Millisecond
+	m.Configure(Config{TimeSetpoint: &tm})
+	if err := m.Err(); err != nil {
+		t.Fatalf("Configure: %v", err)
+	}
+
+	// Re-sending the same duty cycle setpoint should not issue another
+	// write: the cache from the first call must survive the second
+	// Configure call, which only touched TimeSetpoint.
+	m.Configure(Config{DutyCycleSetpoint: &sp})
+	if err := m.Err(); err != nil {
+		t.Fatalf("Configure: %v", err)
+	}
+
+	if n := rec.writes[dutyCycleSetpoint]; n != 1 {
+		t.Errorf("got %d writes to %s, want 1", n, dutyCycleSetpoint)
+	}
+	if n := rec.writes[timeSetpoint]; n != 1 {
+		t.Errorf("got %d writes to %s, want 1", n, timeSetpoint)
+	}
+}
@@ -0,0 +1,200 @@
+// Copyright ©2016 The ev3go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package drive provides a differential-drive controller for a pair of
+// motors acting as independent left and right wheels.
+package drive
+
+import (
+	"math"
+	"sync"
+
+	"github.com/kortschak/ev3/ev3dev"
+)
+
+// Motor is the subset of the DCMotor API that Drive needs to actuate one
+// side of a differential drive.
+type Motor interface {
+	// SetDutyCycleSetpoint sets the motor's duty cycle setpoint, in the
+	// range [-100, 100].
+	SetDutyCycleSetpoint(sp int)
+	// Command issues the named command to the motor.
+	Command(comm string)
+	// Err returns and clears the motor's error state.
+	Err() error
+}
+
+type dcMotor struct{ m *ev3dev.DCMotor }
+
+func (d dcMotor) SetDutyCycleSetpoint(sp int) { d.m.SetDutyCycleSetpoint(sp) }
+func (d dcMotor) Command(comm string)         { d.m.Command(comm) }
+func (d dcMotor) Err() error                  { return d.m.Err() }
+
+// DC adapts a *ev3dev.DCMotor for use as one side of a Drive.
+func DC(m *ev3dev.DCMotor) Motor { return dcMotor{m} }
+
+// ControlInput is a single two-axis control sample delivered to a Drive.
+// Its interpretation is determined by the Drive's current Mixer.
+type ControlInput struct {
+	X, Y float64
+}
+
+// Mixer maps a ControlInput onto left and right duty cycle setpoints, in
+// the range [-100, 100].
+type Mixer func(in ControlInput) (left, right float64)
+
+// Tank is a Mixer that maps each axis directly onto one motor: X drives
+// the left motor and Y drives the right.
+func Tank(in ControlInput) (left, right float64) {
+	return clampPct(in.X), clampPct(in.Y)
+}
+
+// Joystick is a Mixer that mixes a single stick into left and right duty
+// cycles using the standard y+x, y-x differential-drive mix, clamped to
+// [-100, 100].
+func Joystick(in ControlInput) (left, right float64) {
+	return clampPct(in.Y + in.X), clampPct(in.Y - in.X)
+}
+
+func clampPct(v float64) float64 {
+	switch {
+	case v > 100:
+		return 100
+	case v < -100:
+		return -100
+	default:
+		return v
+	}
+}
+
+// Drive is a differential-drive controller for a pair of motors acting as
+// independent left and right wheels. A Drive owns a control loop goroutine
+// that applies incoming ControlInput samples using the current Mixer, and
+// coalesces duty cycle writes so that repeated identical samples do not
+// generate redundant sysfs traffic.
+type Drive struct {
+	left, right Motor
+
+	mu    sync.Mutex
+	mixer Mixer
+	stop  string
+
+	in   chan ControlInput
+	done chan struct{}
+	once sync.Once
+
+	errMu sync.Mutex
+	err   error
+}
+
+// New returns a Drive actuating the given left and right motors and starts
+// its control loop. The default control mode is Tank and the default stop
+// action is "stop".
+func New(left, right Motor) *Drive {
+	d := &Drive{
+		left:  left,
+		right: right,
+		mixer: Tank,
+		stop:  "stop",
+		in:    make(chan ControlInput),
+		done:  make(chan struct{}),
+	}
+	go d.loop()
+	return d
+}
+
+// SetMixer sets the control mode used to interpret ControlInput samples
+// delivered after the call returns. It may be called concurrently with
+// Sample.
+func (d *Drive) SetMixer(m Mixer) {
+	d.mu.Lock()
+	d.mixer = m
+	d.mu.Unlock()
+}
+
+// SetStopAction sets the command issued to both motors by Stop. The
+// default is "stop".
+func (d *Drive) SetStopAction(action string) {
+	d.mu.Lock()
+	d.stop = action
+	d.mu.Unlock()
+}
+
+// Sample delivers a new control input to the drive's control loop. It
+// blocks until the loop is ready to accept it or the Drive has been
+// stopped, so callers running at a fixed rate (a gamepad or sensor poll
+// loop) should call it from their own goroutine.
+func (d *Drive) Sample(ctrl ControlInput) {
+	select {
+	case d.in <- ctrl:
+	case <-d.done:
+	}
+}
+
+func (d *Drive) loop() {
+	var lastLeft, lastRight float64
+	first := true
+	for {
+		select {
+		case ctrl := <-d.in:
+			d.mu.Lock()
+			mix := d.mixer
+			d.mu.Unlock()
+			left, right := mix(ctrl)
+			if first || left != lastLeft {
+				d.left.SetDutyCycleSetpoint(int(math.Round(left)))
+				d.recordErr(d.left.Err())
+				lastLeft = left
+			}
+			if first || right != lastRight {
+				d.right.SetDutyCycleSetpoint(int(math.Round(right)))
+				d.recordErr(d.right.Err())
+				lastRight = right
+			}
+			first = false
+		case <-d.done:
+			return
+		}
+	}
+}
+
+// Stop issues the configured stop action to both motors and halts the
+// control loop. It is safe to call Stop more than once, including
+// concurrently: only the first call issues the stop action, and every
+// call blocks until it has been issued.
+func (d *Drive) Stop() {
+	d.once.Do(func() {
+		close(d.done)
+
+		d.mu.Lock()
+		stop := d.stop
+		d.mu.Unlock()
+
+		d.left.Command(stop)
+		d.recordErr(d.left.Err())
+		d.right.Command(stop)
+		d.recordErr(d.right.Err())
+	})
+}
+
+func (d *Drive) recordErr(err error) {
+	if err == nil {
+		return
+	}
+	d.errMu.Lock()
+	if d.err == nil {
+		d.err = err
+	}
+	d.errMu.Unlock()
+}
+
+// Err returns the first error encountered by either motor since the last
+// call to Err, and clears it.
+func (d *Drive) Err() error {
+	d.errMu.Lock()
+	err := d.err
+	d.err = nil
+	d.errMu.Unlock()
+	return err
+}
@@ -0,0 +1,160 @@
+// Copyright ©2016 The ev3go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package drive
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestTank(t *testing.T) {
+	for _, test := range []struct {
+		in          ControlInput
+		left, right float64
+	}{
+		{in: ControlInput{X: 0, Y: 0}, left: 0, right: 0},
+		{in: ControlInput{X: 50, Y: -30}, left: 50, right: -30},
+		{in: ControlInput{X: 150, Y: -150}, left: 100, right: -100},
+	} {
+		left, right := Tank(test.in)
+		if left != test.left || right != test.right {
+			t.Errorf("Tank(%+v) = %v, %v, want %v, %v", test.in, left, right, test.left, test.right)
+		}
+	}
+}
+
+func TestJoystick(t *testing.T) {
+	for _, test := range []struct {
+		in          ControlInput
+		left, right float64
+	}{
+		{in: ControlInput{X: 0, Y: 0}, left: 0, right: 0},
+		{in: ControlInput{X: 0, Y: 100}, left: 100, right: 100},
+		{in: ControlInput{X: 100, Y: 0}, left: 100, right: -100},
+		{in: ControlInput{X: -100, Y: 0}, left: -100, right: 100},
+		{in: ControlInput{X: 100, Y: 100}, left: 100, right: 0},
+	} {
+		left, right := Joystick(test.in)
+		if left != test.left || right != test.right {
+			t.Errorf("Joystick(%+v) = %v, %v, want %v, %v", test.in, left, right, test.left, test.right)
+		}
+	}
+}
+
+type fakeMotor struct {
+	mu  sync.Mutex
+	sp  []int
+	cmd []string
+}
+
+func (f *fakeMotor) SetDutyCycleSetpoint(sp int) {
+	f.mu.Lock()
+	f.sp = append(f.sp, sp)
+	f.mu.Unlock()
+}
+
+func (f *fakeMotor) Command(comm string) {
+	f.mu.Lock()
+	f.cmd = append(f.cmd, comm)
+	f.mu.Unlock()
+}
+
+func (f *fakeMotor) Err() error { return nil }
+
+func (f *fakeMotor) setpoints() []int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]int(nil), f.sp...)
+}
+
+func (f *fakeMotor) commands() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.cmd...)
+}
+
+func TestSampleCoalescesWrites(t *testing.T) {
+	left := &fakeMotor{}
+	right := &fakeMotor{}
+	d := New(left, right)
+	defer d.Stop()
+
+	// Tank is the default mode: X drives left, Y drives right directly.
+	d.Sample(ControlInput{X: 40, Y: 40})
+	d.Sample(ControlInput{X: 40, Y: 40})
+	time.Sleep(20 * time.Millisecond)
+
+	if got := left.setpoints(); len(got) != 1 || got[0] != 40 {
+		t.Errorf("after two identical samples, left writes = %v, want [40]", got)
+	}
+	if got := right.setpoints(); len(got) != 1 || got[0] != 40 {
+		t.Errorf("after two identical samples, right writes = %v, want [40]", got)
+	}
+
+	d.Sample(ControlInput{X: -20, Y: -20})
+	time.Sleep(20 * time.Millisecond)
+
+	if got := left.setpoints(); len(got) != 2 || got[1] != -20 {
+		t.Errorf("after a changed sample, left writes = %v, want a second write of -20", got)
+	}
+	if got := right.setpoints(); len(got) != 2 || got[1] != -20 {
+		t.Errorf("after a changed sample, right writes = %v, want a second write of -20", got)
+	}
+}
+
+func TestSetMixerSwapsInFlight(t *testing.T) {
+	left := &fakeMotor{}
+	right := &fakeMotor{}
+	d := New(left, right)
+	defer d.Stop()
+
+	// Tank: X drives left, Y drives right directly.
+	d.Sample(ControlInput{X: 50, Y: -50})
+	time.Sleep(20 * time.Millisecond)
+
+	d.SetMixer(Joystick)
+	// Same raw input, now mixed as a single stick: left = Y+X, right = Y-X.
+	d.Sample(ControlInput{X: 50, Y: -50})
+	time.Sleep(20 * time.Millisecond)
+
+	if got := left.setpoints(); len(got) != 2 || got[0] != 50 || got[1] != 0 {
+		t.Errorf("left writes = %v, want [50 0]", got)
+	}
+	if got := right.setpoints(); len(got) != 2 || got[0] != -50 || got[1] != -100 {
+		t.Errorf("right writes = %v, want [-50 -100]", got)
+	}
+}
+
+func TestStopConcurrent(t *testing.T) {
+	d := New(&fakeMotor{}, &fakeMotor{})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			d.Stop()
+		}()
+	}
+	wg.Wait()
+}
+
+func TestStopIssuesCommandOnce(t *testing.T) {
+	left := &fakeMotor{}
+	right := &fakeMotor{}
+	d := New(left, right)
+
+	d.Stop()
+	d.Stop()
+	d.Stop()
+
+	if got := left.commands(); len(got) != 1 || got[0] != "stop" {
+		t.Errorf("left commands = %v, want a single [stop]", got)
+	}
+	if got := right.commands(); len(got) != 1 || got[0] != "stop" {
+		t.Errorf("right commands = %v, want a single [stop]", got)
+	}
+}
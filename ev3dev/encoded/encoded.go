@@ -0,0 +1,272 @@
+// Copyright ©2016 The ev3go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package encoded provides a closed-loop speed and position wrapper
+// around ev3dev's open-loop DCMotor, using an external encoder as the
+// feedback source.
+package encoded
+
+import (
+	"math"
+	"sync"
+	"time"
+
+	"github.com/kortschak/ev3/ev3dev"
+)
+
+// Encoder is a source of rotation counts for a motor. It is satisfied by
+// an *ev3dev.Sensor reading a rotation sensor, or by a *ev3dev.TachoMotor
+// used purely for its tacho counts.
+type Encoder interface {
+	// Position returns the current encoder count.
+	Position() (int, error)
+	// CountsPerRev returns the number of encoder counts per revolution.
+	CountsPerRev() int
+}
+
+// Tuning holds the PID gains and sample rate used by an EncodedDCMotor's
+// control loop.
+type Tuning struct {
+	Kp, Ki, Kd float64
+
+	// MaxIntegral bounds the magnitude of the accumulated integral term,
+	// preventing windup while the duty cycle output is saturated.
+	MaxIntegral float64
+
+	// SampleRate is the period between encoder samples and duty cycle
+	// updates. If zero, a default of 20ms (50Hz) is used.
+	SampleRate time.Duration
+
+	// Accel is the maximum change in target RPM per second used by GoTo
+	// to decelerate smoothly as it approaches a target position. If
+	// zero, GoTo instead uses a simple proportional controller that
+	// linearly scales the target speed down over the final revolution
+	// before the goal.
+	Accel float64
+}
+
+// State is a snapshot of an EncodedDCMotor's control loop for diagnostics.
+type State struct {
+	CurrentRPM float64
+	GoalRPM    float64
+	GoalPos    int
+	HasGoalPos bool
+	LastError  float64
+}
+
+// EncodedDCMotor composes a *ev3dev.DCMotor with an Encoder to provide
+// closed-loop SetRPM and GoTo control, the same "set RPM, go to position"
+// ergonomics that ev3dev's TachoMotor gets from its kernel driver.
+type EncodedDCMotor struct {
+	motor *ev3dev.DCMotor
+	enc   Encoder
+
+	tuning Tuning
+
+	mu      sync.Mutex
+	goalRPM float64
+	goalPos int
+	hasPos  bool
+
+	state State
+
+	done chan struct{}
+	stop chan struct{}
+	once sync.Once
+
+	errMu sync.Mutex
+	err   error
+}
+
+// New returns an EncodedDCMotor driving motor and reading position from
+// enc, and starts its control loop using the given Tuning. A Tuning with
+// a zero SampleRate uses a default period of 20ms.
+func New(motor *ev3dev.DCMotor, enc Encoder, tuning Tuning) *EncodedDCMotor {
+	if tuning.SampleRate <= 0 {
+		tuning.SampleRate = 20 * time.Millisecond
+	}
+	m := &EncodedDCMotor{
+		motor:  motor,
+		enc:    enc,
+		tuning: tuning,
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+	go m.loop()
+	return m
+}
+
+// SetRPM sets the target speed, in revolutions per minute, for the motor
+// to hold under closed-loop control. A positive rpm drives the motor
+// forward and a negative rpm drives it in reverse.
+func (m *EncodedDCMotor) SetRPM(rpm float64) {
+	m.mu.Lock()
+	m.goalRPM = rpm
+	m.hasPos = false
+	m.mu.Unlock()
+}
+
+// GoTo drives the motor to the given encoder position at up to rpm,
+// decelerating as it approaches the target: at Tuning.Accel when that is
+// set, and otherwise linearly over the final revolution.
+func (m *EncodedDCMotor) GoTo(position int, rpm float64) {
+	m.mu.Lock()
+	m.goalPos = position
+	m.goalRPM = math.Abs(rpm)
+	m.hasPos = true
+	m.mu.Unlock()
+}
+
+// State returns a snapshot of the control loop's current goal and error,
+// for diagnostics.
+func (m *EncodedDCMotor) State() State {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.state
+}
+
+// Err returns the first error encountered by the motor or encoder since
+// the last call to Err, and clears it.
+func (m *EncodedDCMotor) Err() error {
+	m.errMu.Lock()
+	err := m.err
+	m.err = nil
+	m.errMu.Unlock()
+	return err
+}
+
+func (m *EncodedDCMotor) recordErr(err error) {
+	if err == nil {
+		return
+	}
+	m.errMu.Lock()
+	if m.err == nil {
+		m.err = err
+	}
+	m.errMu.Unlock()
+}
+
+// Stop tears down the control loop and issues the DCMotor's configured
+// stop action. It is safe to call Stop more than once, including
+// concurrently: only the first call issues the stop action, and every
+// call blocks until it has been issued.
+func (m *EncodedDCMotor) Stop() {
+	m.once.Do(func() {
+		close(m.stop)
+		<-m.done
+
+		m.motor.Command("stop")
+		m.recordErr(m.motor.Err())
+	})
+}
+
+func (m *EncodedDCMotor) loop() {
+	defer close(m.done)
+
+	period := m.tuning.SampleRate
+	ticker := time.NewTicker(period)
+	defer ticker.Stop()
+
+	dt := period.Seconds()
+	cpr := m.enc.CountsPerRev()
+
+	var integral, lastErr float64
+	var lastPos int
+	havePos := false
+	if pos, err := m.enc.Position(); err == nil {
+		lastPos = pos
+		havePos = true
+	} else {
+		m.recordErr(err)
+	}
+
+	for {
+		select {
+		case <-m.stop:
+			return
+		case <-ticker.C:
+		}
+
+		pos, err := m.enc.Position()
+		if err != nil {
+			m.recordErr(err)
+			continue
+		}
+		if !havePos {
+			lastPos = pos
+			havePos = true
+		}
+		currentRPM := float64(pos-lastPos) / float64(cpr) / dt * 60
+		lastPos = pos
+
+		m.mu.Lock()
+		goalRPM := m.goalRPM
+		if m.hasPos {
+			remaining := m.goalPos - pos
+			target := math.Copysign(goalRPM, float64(remaining))
+			remainingRev := math.Abs(float64(remaining)) / float64(cpr)
+			if m.tuning.Accel > 0 {
+				// Trapezoidal profile: cap the target speed so the
+				// remaining distance is enough to decelerate to a stop
+				// at Accel (given in RPM/s) before overshooting.
+				decel := math.Sqrt(2*m.tuning.Accel/60*remainingRev) * 60
+				if decel < math.Abs(target) {
+					target = math.Copysign(decel, target)
+				}
+			} else {
+				// Simple proportional position controller: scale the
+				// target speed down linearly over the last revolution
+				// before the goal so the motor settles instead of
+				// running at goalRPM until it overshoots.
+				const band = 1 // revolution
+				if remainingRev < band {
+					scaled := goalRPM * remainingRev / band
+					if scaled < math.Abs(target) {
+						target = math.Copysign(scaled, target)
+					}
+				}
+			}
+			if math.Abs(float64(remaining)) < 1 {
+				target = 0
+			}
+			goalRPM = target
+		}
+		m.mu.Unlock()
+
+		perr := goalRPM - currentRPM
+		integral += perr * dt
+		if m.tuning.MaxIntegral > 0 {
+			switch {
+			case integral > m.tuning.MaxIntegral:
+				integral = m.tuning.MaxIntegral
+			case integral < -m.tuning.MaxIntegral:
+				integral = -m.tuning.MaxIntegral
+			}
+		}
+		derivative := (perr - lastErr) / dt
+		lastErr = perr
+
+		out := m.tuning.Kp*perr + m.tuning.Ki*integral + m.tuning.Kd*derivative
+		sp := int(math.Round(out))
+		switch {
+		case sp > 100:
+			sp = 100
+		case sp < -100:
+			sp = -100
+		}
+
+		m.motor.SetDutyCycleSetpoint(sp)
+		m.recordErr(m.motor.Err())
+
+		m.mu.Lock()
+		m.state = State{
+			CurrentRPM: currentRPM,
+			GoalRPM:    goalRPM,
+			GoalPos:    m.goalPos,
+			HasGoalPos: m.hasPos,
+			LastError:  perr,
+		}
+		m.mu.Unlock()
+	}
+}
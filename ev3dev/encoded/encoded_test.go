@@ -0,0 +1,144 @@
+// Copyright ©2016 The ev3go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package encoded
+
+import (
+	"errors"
+	"math"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/kortschak/ev3/ev3dev"
+)
+
+type fakeEncoder struct {
+	mu  sync.Mutex
+	pos int
+}
+
+func (f *fakeEncoder) Position() (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.pos, nil
+}
+
+func (f *fakeEncoder) CountsPerRev() int { return 360 }
+
+// failFirstEncoder fails its first Position call, then behaves like a
+// fakeEncoder, exercising the loop's recovery from an initial read error.
+type failFirstEncoder struct {
+	fakeEncoder
+	failed bool
+}
+
+func (f *failFirstEncoder) Position() (int, error) {
+	if !f.failed {
+		f.failed = true
+		return 0, errors.New("encoder not ready")
+	}
+	return f.fakeEncoder.Position()
+}
+
+func TestStopConcurrent(t *testing.T) {
+	m := New(&ev3dev.DCMotor{}, &fakeEncoder{}, Tuning{})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			m.Stop()
+		}()
+	}
+	wg.Wait()
+}
+
+// dutyCycleTracer records every duty_cycle_sp value written through
+// ev3dev's Tracer hook, letting a test observe the control loop's
+// commanded output without a fake *ev3dev.DCMotor.
+type dutyCycleTracer struct {
+	mu     sync.Mutex
+	values []int
+}
+
+func (d *dutyCycleTracer) OnRead(dev ev3dev.Device, attr, value string, err error, dur time.Duration) {
+}
+
+func (d *dutyCycleTracer) OnWrite(dev ev3dev.Device, attr, value string, err error, dur time.Duration) {
+	if attr != "duty_cycle_sp" {
+		return
+	}
+	sp, perr := strconv.Atoi(value)
+	if perr != nil {
+		return
+	}
+	d.mu.Lock()
+	d.values = append(d.values, sp)
+	d.mu.Unlock()
+}
+
+func (d *dutyCycleTracer) last() (int, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if len(d.values) == 0 {
+		return 0, false
+	}
+	return d.values[len(d.values)-1], true
+}
+
+func TestSetRPMDirection(t *testing.T) {
+	rec := &dutyCycleTracer{}
+	ev3dev.SetTracer(rec)
+	defer ev3dev.SetTracer(nil)
+
+	m := New(&ev3dev.DCMotor{}, &fakeEncoder{}, Tuning{Kp: 1, SampleRate: 5 * time.Millisecond})
+	defer m.Stop()
+
+	m.SetRPM(100)
+	time.Sleep(50 * time.Millisecond)
+	if v, ok := rec.last(); !ok || v <= 0 {
+		t.Errorf("forward SetRPM: got duty cycle %d (ok=%v), want > 0", v, ok)
+	}
+
+	m.SetRPM(-100)
+	time.Sleep(50 * time.Millisecond)
+	if v, ok := rec.last(); !ok || v >= 0 {
+		t.Errorf("reverse SetRPM: got duty cycle %d (ok=%v), want < 0", v, ok)
+	}
+}
+
+func TestLoopRecoversFromInitialPositionError(t *testing.T) {
+	enc := &failFirstEncoder{fakeEncoder: fakeEncoder{pos: 1000}}
+	m := New(&ev3dev.DCMotor{}, enc, Tuning{Kp: 1, SampleRate: 5 * time.Millisecond})
+	defer m.Stop()
+
+	// The stationary motor's first successful position read must not be
+	// diffed against the zero lastPos left behind by the failed initial
+	// read, which would otherwise report a huge spurious velocity spike.
+	time.Sleep(30 * time.Millisecond)
+	st := m.State()
+	if math.Abs(st.CurrentRPM) > 1 {
+		t.Errorf("CurrentRPM = %v, want ~0 for a stationary motor", st.CurrentRPM)
+	}
+}
+
+func TestGoToDeceleratesNearTarget(t *testing.T) {
+	m := New(&ev3dev.DCMotor{}, &fakeEncoder{}, Tuning{Kp: 1, SampleRate: 5 * time.Millisecond})
+	defer m.Stop()
+
+	// One tenth of a revolution away: within the proportional band, so
+	// the scaled goal RPM should sit strictly between zero and the
+	// requested top speed instead of commanding full speed right up to
+	// the stop.
+	m.GoTo(36, 100)
+	time.Sleep(50 * time.Millisecond)
+
+	st := m.State()
+	if st.GoalRPM <= 0 || st.GoalRPM >= 100 {
+		t.Errorf("GoTo near target: got scaled GoalRPM %v, want in (0, 100)", st.GoalRPM)
+	}
+}
@@ -0,0 +1,64 @@
+// Copyright ©2016 The ev3go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ev3dev
+
+import "time"
+
+// tracedAttributeOf is attributeOf instrumented with the package-level
+// Tracer. DCMotor methods that read a sysfs attribute call this instead
+// of attributeOf directly.
+func tracedAttributeOf(d Device, attr string) (string, time.Duration, error) {
+	value, dur, err := attributeOf(d, attr)
+	tracer.OnRead(d, attr, value, err, dur)
+	return value, dur, err
+}
+
+// tracedSetAttributeOf is setAttributeOf instrumented with the
+// package-level Tracer. DCMotor methods that write a sysfs attribute call
+// this instead of setAttributeOf directly.
+func tracedSetAttributeOf(d Device, attr, value string) error {
+	start := time.Now()
+	err := setAttributeOf(d, attr, value)
+	tracer.OnWrite(d, attr, value, err, time.Since(start))
+	return err
+}
+
+// Tracer observes sysfs attribute reads and writes performed on behalf
+// of a Device. Only the attribute I/O of DCMotor methods is reported;
+// other device types do not call the Tracer. Implementations must be
+// safe for concurrent use, since the traced attribute functions may be
+// called from multiple goroutines driving different devices.
+type Tracer interface {
+	// OnRead is called after an attempt to read attr from dev, with the
+	// value read (if any), the resulting error, and how long the read
+	// took.
+	OnRead(dev Device, attr string, value string, err error, dur time.Duration)
+
+	// OnWrite is called after an attempt to write value to attr on dev,
+	// with the resulting error and how long the write took.
+	OnWrite(dev Device, attr string, value string, err error, dur time.Duration)
+}
+
+// tracer is the package-level Tracer used by tracedAttributeOf and
+// tracedSetAttributeOf. It defaults to a no-op so that tracing has no cost
+// until SetTracer is called.
+var tracer Tracer = noopTracer{}
+
+// SetTracer installs t as the package-level Tracer, replacing whatever
+// Tracer was previously installed. Passing nil restores the default
+// no-op Tracer. SetTracer is not safe for concurrent use with device
+// attribute access and should be called during program setup, before
+// devices are driven.
+func SetTracer(t Tracer) {
+	if t == nil {
+		t = noopTracer{}
+	}
+	tracer = t
+}
+
+type noopTracer struct{}
+
+func (noopTracer) OnRead(dev Device, attr, value string, err error, dur time.Duration)  {}
+func (noopTracer) OnWrite(dev Device, attr, value string, err error, dur time.Duration) {}
@@ -0,0 +1,102 @@
+// Copyright ©2016 The ev3go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package trace provides ev3dev.Tracer implementations for logging and
+// collecting metrics on sysfs attribute I/O.
+package trace
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/kortschak/ev3/ev3dev"
+)
+
+// Slog is an ev3dev.Tracer that logs attribute reads and writes to a
+// log/slog.Logger. Reads and writes that return an error are logged at
+// slog.LevelError; others are logged at slog.LevelDebug.
+type Slog struct {
+	Logger *slog.Logger
+}
+
+var _ ev3dev.Tracer = Slog{}
+
+// OnRead logs a completed attribute read.
+func (s Slog) OnRead(dev ev3dev.Device, attr, value string, err error, dur time.Duration) {
+	s.log(dev, "read", attr, value, err, dur)
+}
+
+// OnWrite logs a completed attribute write.
+func (s Slog) OnWrite(dev ev3dev.Device, attr, value string, err error, dur time.Duration) {
+	s.log(dev, "write", attr, value, err, dur)
+}
+
+func (s Slog) log(dev ev3dev.Device, op, attr, value string, err error, dur time.Duration) {
+	level := slog.LevelDebug
+	if err != nil {
+		level = slog.LevelError
+	}
+	s.Logger.Log(context.Background(), level, "ev3dev attribute I/O",
+		"op", op,
+		"device", dev.String(),
+		"attr", attr,
+		"value", value,
+		"err", err,
+		"duration", dur,
+	)
+}
+
+// Metrics is a snapshot of the counts and durations collected by a
+// Collector.
+type Metrics struct {
+	Reads, ReadErrors   uint64
+	Writes, WriteErrors uint64
+	ReadDuration        time.Duration
+	WriteDuration       time.Duration
+}
+
+// Collector is an ev3dev.Tracer that accumulates Prometheus-style
+// counters of attribute I/O: counts of reads and writes, their error
+// counts, and their cumulative duration. Durations are kept only as
+// totals; no per-call distribution (histogram) is recorded. Use Metrics
+// to obtain a snapshot suitable for exporting.
+type Collector struct {
+	mu sync.Mutex
+	m  Metrics
+}
+
+var _ ev3dev.Tracer = (*Collector)(nil)
+
+// OnRead records a completed attribute read.
+func (c *Collector) OnRead(dev ev3dev.Device, attr, value string, err error, dur time.Duration) {
+	c.mu.Lock()
+	c.m.Reads++
+	if err != nil {
+		c.m.ReadErrors++
+	}
+	c.m.ReadDuration += dur
+	c.mu.Unlock()
+}
+
+// OnWrite records a completed attribute write.
+func (c *Collector) OnWrite(dev ev3dev.Device, attr, value string, err error, dur time.Duration) {
+	c.mu.Lock()
+	c.m.Writes++
+	if err != nil {
+		c.m.WriteErrors++
+	}
+	c.m.WriteDuration += dur
+	c.mu.Unlock()
+}
+
+// Metrics returns a snapshot of the counters and durations collected so
+// far.
+func (c *Collector) Metrics() Metrics {
+	c.mu.Lock()
+	m := c.m
+	c.mu.Unlock()
+	return m
+}
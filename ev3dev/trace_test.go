@@ -0,0 +1,44 @@
+// Copyright ©2016 The ev3go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ev3dev
+
+import (
+	"testing"
+	"time"
+)
+
+type recordingTracer struct {
+	reads, writes int
+}
+
+func (r *recordingTracer) OnRead(dev Device, attr, value string, err error, dur time.Duration) {
+	r.reads++
+}
+
+func (r *recordingTracer) OnWrite(dev Device, attr, value string, err error, dur time.Duration) {
+	r.writes++
+}
+
+func TestTracerCalledOnAttributeIO(t *testing.T) {
+	rec := &recordingTracer{}
+	SetTracer(rec)
+	defer SetTracer(nil)
+
+	m := &DCMotor{id: 0}
+	m.SetDutyCycleSetpoint(50)
+	if err := m.Err(); err != nil {
+		t.Fatalf("SetDutyCycleSetpoint: %v", err)
+	}
+	if rec.writes == 0 {
+		t.Error("expected at least one OnWrite call, got none")
+	}
+
+	if _, err := m.DutyCycle(); err != nil {
+		t.Fatalf("DutyCycle: %v", err)
+	}
+	if rec.reads == 0 {
+		t.Error("expected at least one OnRead call, got none")
+	}
+}